@@ -0,0 +1,189 @@
+// Copyright 2021 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/perillo/go-portable/internal/whitelist"
+	"github.com/perillo/go-portable/portable"
+)
+
+// report prints results in the requested format, and returns the number of
+// results with at least one diagnostic (a platform verified with both
+// CGO_ENABLED values contributes two results).
+func report(results []portable.Result, format string) int {
+	switch format {
+	case "json":
+		return reportJSON(results)
+	case "sarif":
+		return reportSARIF(results)
+	default:
+		return reportText(results)
+	}
+}
+
+// reportText prints one diagnostic block per platform, in the traditional
+// go-portable plain text format.
+func reportText(results []portable.Result) int {
+	nl := []byte("\n")
+	failures := 0
+
+	for _, r := range results {
+		if len(r.Diagnostics) == 0 {
+			continue
+		}
+
+		if failures > 0 {
+			os.Stderr.Write(nl)
+		}
+		header := r.Platform.String()
+		if r.CGOEnabled != "" {
+			header += " CGO_ENABLED=" + r.CGOEnabled
+		}
+		fmt.Fprintf(os.Stderr, "%s using %s\n", header, gocmdshort)
+		for _, d := range r.Diagnostics {
+			fmt.Fprintln(os.Stderr, whitelist.Line(d.File, d.Line, d.Column, d.Message))
+		}
+		failures++
+	}
+
+	return failures
+}
+
+// reportJSON prints a stream of one JSON encoded portable.Result per
+// platform.
+func reportJSON(results []portable.Result) int {
+	enc := json.NewEncoder(os.Stdout)
+	failures := 0
+
+	for _, r := range results {
+		if err := enc.Encode(r); err != nil {
+			fmt.Fprintf(os.Stderr, "%s/%s: %v\n", r.Platform.OS, r.Platform.Arch, err)
+		}
+		if len(r.Diagnostics) > 0 {
+			failures++
+		}
+	}
+
+	return failures
+}
+
+// reportSARIF aggregates every result into a single SARIF v2.1.0 run
+// document, with one result per diagnostic, and prints it to standard
+// output.
+func reportSARIF(results []portable.Result) int {
+	failures := 0
+	for _, r := range results {
+		if len(r.Diagnostics) > 0 {
+			failures++
+		}
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(buildSARIF(results)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+
+	return failures
+}
+
+// SARIF v2.1.0 document, restricted to the fields go-portable populates.
+// See https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html.
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name string `json:"name"`
+}
+
+type sarifResult struct {
+	RuleID     string              `json:"ruleId"`
+	Message    sarifMessage        `json:"message"`
+	Locations  []sarifLocation     `json:"locations,omitempty"`
+	Properties sarifResultProperty `json:"properties"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine,omitempty"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+type sarifResultProperty struct {
+	Platform string `json:"platform"`
+}
+
+// buildSARIF aggregates every platform result into a single SARIF run,
+// with one result per diagnostic.
+func buildSARIF(results []portable.Result) sarifLog {
+	doc := sarifLog{
+		Schema:  "https://raw.githubusercontent.com/oasis-tcs/sarif-spec/master/Schemata/sarif-schema-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{Name: "go-portable"}},
+		}},
+	}
+
+	run := &doc.Runs[0]
+	for _, r := range results {
+		platform := r.Platform.String()
+		for _, d := range r.Diagnostics {
+			ruleID := d.Analyzer
+			if ruleID == "" {
+				ruleID = r.Tool
+			}
+
+			res := sarifResult{
+				RuleID:     ruleID,
+				Message:    sarifMessage{Text: d.Message},
+				Properties: sarifResultProperty{Platform: platform},
+			}
+			if d.File != "" {
+				res.Locations = []sarifLocation{{
+					PhysicalLocation: sarifPhysicalLocation{
+						ArtifactLocation: sarifArtifactLocation{URI: d.File},
+						Region:           sarifRegion{StartLine: d.Line, StartColumn: d.Column},
+					},
+				}}
+			}
+
+			run.Results = append(run.Results, res)
+		}
+	}
+
+	return doc
+}
@@ -0,0 +1,186 @@
+// Copyright 2021 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/perillo/go-portable/portable"
+)
+
+// captureStdout redirects os.Stdout for the duration of fn, and returns
+// everything written to it.
+func captureStdout(t *testing.T, fn func()) []byte {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("captureStdout: %v", err)
+	}
+
+	saved := os.Stdout
+	os.Stdout = w
+	fn()
+	os.Stdout = saved
+	w.Close()
+
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(r); err != nil {
+		t.Fatalf("captureStdout: %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+func testResults() []portable.Result {
+	return []portable.Result{
+		{
+			Platform: portable.Platform{OS: "linux", Arch: "amd64"},
+			Tool:     "vet",
+			Diagnostics: []portable.Diagnostic{
+				{Analyzer: "printf", File: "a.go", Line: 6, Column: 2, Message: "bad format"},
+			},
+		},
+		{
+			Platform: portable.Platform{OS: "windows", Arch: "386"},
+			Tool:     "vet",
+		},
+	}
+}
+
+// TestReportJSON tests that reportJSON emits one JSON encoded
+// portable.Result per platform, and counts only platforms with
+// diagnostics as failures.
+func TestReportJSON(t *testing.T) {
+	results := testResults()
+
+	var failures int
+	out := captureStdout(t, func() {
+		failures = reportJSON(results)
+	})
+	if failures != 1 {
+		t.Errorf("want 1 failure, got %d", failures)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(out))
+	var got []portable.Result
+	for dec.More() {
+		var r portable.Result
+		if err := dec.Decode(&r); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		got = append(got, r)
+	}
+	if len(got) != len(results) {
+		t.Fatalf("want %d results, got %d", len(results), len(got))
+	}
+	if got[0].Platform != results[0].Platform || len(got[0].Diagnostics) != 1 {
+		t.Errorf("want %+v, got %+v", results[0], got[0])
+	}
+}
+
+// TestReportSARIF tests that reportSARIF emits a single SARIF document
+// with one result per diagnostic, and counts only platforms with
+// diagnostics as failures.
+func TestReportSARIF(t *testing.T) {
+	results := testResults()
+
+	var failures int
+	out := captureStdout(t, func() {
+		failures = reportSARIF(results)
+	})
+	if failures != 1 {
+		t.Errorf("want 1 failure, got %d", failures)
+	}
+
+	var doc sarifLog
+	if err := json.Unmarshal(out, &doc); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if len(doc.Runs) != 1 {
+		t.Fatalf("want 1 run, got %d", len(doc.Runs))
+	}
+	if doc.Runs[0].Tool.Driver.Name != "go-portable" {
+		t.Errorf("want driver name go-portable, got %s", doc.Runs[0].Tool.Driver.Name)
+	}
+	if len(doc.Runs[0].Results) != 1 {
+		t.Fatalf("want 1 sarif result, got %d", len(doc.Runs[0].Results))
+	}
+
+	res := doc.Runs[0].Results[0]
+	if res.RuleID != "printf" {
+		t.Errorf("want ruleId printf, got %s", res.RuleID)
+	}
+	if res.Properties.Platform != "linux/amd64" {
+		t.Errorf("want platform linux/amd64, got %s", res.Properties.Platform)
+	}
+	if len(res.Locations) != 1 || res.Locations[0].PhysicalLocation.ArtifactLocation.URI != "a.go" {
+		t.Errorf("want location uri a.go, got %+v", res.Locations)
+	}
+}
+
+// TestBuildSARIFNoLocation tests that buildSARIF omits locations for a
+// diagnostic with no file, and falls back to the tool name for ruleId
+// when the diagnostic has no analyzer.
+func TestBuildSARIFNoLocation(t *testing.T) {
+	results := []portable.Result{
+		{
+			Platform:    portable.Platform{OS: "linux", Arch: "arm", GOARM: "6"},
+			Tool:        "build",
+			Diagnostics: []portable.Diagnostic{{Message: "undefined: foo"}},
+		},
+	}
+
+	doc := buildSARIF(results)
+	if len(doc.Runs[0].Results) != 1 {
+		t.Fatalf("want 1 result, got %d", len(doc.Runs[0].Results))
+	}
+
+	res := doc.Runs[0].Results[0]
+	if res.RuleID != "build" {
+		t.Errorf("want ruleId build, got %s", res.RuleID)
+	}
+	if res.Locations != nil {
+		t.Errorf("want no locations, got %+v", res.Locations)
+	}
+	if res.Properties.Platform != "linux/arm GOARM=6" {
+		t.Errorf("want platform linux/arm GOARM=6, got %s", res.Properties.Platform)
+	}
+}
+
+// TestReportText tests that reportText prints one block per platform with
+// diagnostics, skipping platforms with none.
+func TestReportText(t *testing.T) {
+	results := testResults()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("pipe: %v", err)
+	}
+	saved := os.Stderr
+	os.Stderr = w
+
+	failures := reportText(results)
+
+	os.Stderr = saved
+	w.Close()
+
+	if failures != 1 {
+		t.Errorf("want 1 failure, got %d", failures)
+	}
+
+	sc := bufio.NewScanner(r)
+	var lines []string
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+	}
+	if len(lines) != 2 {
+		t.Fatalf("want 2 lines, got %d: %q", len(lines), lines)
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright 2021 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package whitelist implements whitelist/baseline files for known-failing
+// platform diagnostics, mirroring the approach used by the old cmd/vet/all
+// tool.
+//
+// A whitelist file contains one entry per line, in the form
+//
+//	N diagnostic message
+//
+// where N is the number of times the diagnostic message is expected to be
+// reported.  Blank lines and lines starting with # are ignored.
+package whitelist
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Entry is a whitelisted diagnostic that was never matched, or not matched
+// as many times as expected.
+type Entry struct {
+	File    string // whitelist file the entry comes from
+	Message string
+	Want    int // number of occurrences expected
+	Got     int // number of occurrences actually matched
+}
+
+// lineNumber matches the line number in a "file.go:42:" style prefix.
+var lineNumber = regexp.MustCompile(`:\d+:`)
+
+// Strip normalizes a diagnostic line by removing its line number, so that
+// whitelist entries survive line shuffling in the checked package.
+func Strip(line string) string {
+	return lineNumber.ReplaceAllString(line, ":")
+}
+
+// Line formats a diagnostic as a single line, in the same shape used by
+// whitelist files, so that it can be matched against a Store.
+func Line(file string, lineno, col int, message string) string {
+	switch {
+	case file == "":
+		return message
+	case col > 0:
+		return fmt.Sprintf("%s:%d:%d: %s", file, lineno, col, message)
+	default:
+		return fmt.Sprintf("%s:%d: %s", file, lineno, message)
+	}
+}
+
+// file is a single whitelist file, shared by every platform that consults
+// it (e.g. all.txt applies to every platform).
+type file struct {
+	mu   sync.Mutex
+	want map[string]int
+	got  map[string]int
+}
+
+func loadFile(path string) (*file, error) {
+	f := &file{want: make(map[string]int), got: make(map[string]int)}
+
+	r, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.SplitN(line, " ", 2)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s: invalid whitelist entry: %q", path, line)
+		}
+		count, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid whitelist entry: %q", path, line)
+		}
+
+		f.want[fields[1]] += count
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return f, nil
+}
+
+// match reports whether key is whitelisted, recording the match.  It is
+// safe for concurrent use by multiple platforms.
+func (f *file) match(key string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.want[key]; !ok {
+		return false
+	}
+	f.got[key]++
+
+	return true
+}
+
+func (f *file) unused(name string) []Entry {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var unused []Entry
+	for msg, want := range f.want {
+		if got := f.got[msg]; got < want {
+			unused = append(unused, Entry{File: name, Message: msg, Want: want, Got: got})
+		}
+	}
+
+	return unused
+}
+
+// Store loads and caches whitelist files from a directory, and matches
+// per-platform diagnostics against them.
+//
+// A platform is matched against both its own file (e.g. linux_arm.txt) and
+// all.txt, which applies to every platform.  Store is safe for concurrent
+// use by multiple goroutines, so that it can be shared by a worker pool.
+type Store struct {
+	dir        string
+	stripLines bool
+
+	mu    sync.Mutex
+	files map[string]*file
+}
+
+// NewStore returns a Store that reads whitelist files from dir.  When
+// stripLines is true, line numbers are stripped from a diagnostic before
+// matching it against the whitelist, via Strip.
+func NewStore(dir string, stripLines bool) *Store {
+	return &Store{
+		dir:        dir,
+		stripLines: stripLines,
+		files:      make(map[string]*file),
+	}
+}
+
+func (s *Store) file(name string) (*file, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if f, ok := s.files[name]; ok {
+		return f, nil
+	}
+
+	f, err := loadFile(filepath.Join(s.dir, name))
+	if err != nil {
+		return nil, err
+	}
+	s.files[name] = f
+
+	return f, nil
+}
+
+// Match reports whether the diagnostic line is whitelisted for the given
+// platform, consulting both the platform specific file (e.g.
+// linux_arm.txt) and all.txt.  os and arch identify the platform, e.g.
+// "linux" and "arm".
+func (s *Store) Match(os, arch, line string) (bool, error) {
+	key := line
+	if s.stripLines {
+		key = Strip(line)
+	}
+
+	matched := false
+	for _, name := range []string{os + "_" + arch + ".txt", "all.txt"} {
+		f, err := s.file(name)
+		if err != nil {
+			return false, err
+		}
+
+		if f.match(key) {
+			matched = true
+		}
+	}
+
+	return matched, nil
+}
+
+// Unused returns the whitelist entries that were never matched, or not
+// matched as many times as expected, across every platform that was
+// checked.  It lets users prune whitelist entries that are no longer
+// triggered by any platform.
+func (s *Store) Unused() []Entry {
+	s.mu.Lock()
+	names := make([]string, 0, len(s.files))
+	for name := range s.files {
+		names = append(names, name)
+	}
+	s.mu.Unlock()
+
+	sort.Strings(names)
+
+	var unused []Entry
+	for _, name := range names {
+		s.mu.Lock()
+		f := s.files[name]
+		s.mu.Unlock()
+
+		unused = append(unused, f.unused(name)...)
+	}
+
+	return unused
+}
@@ -0,0 +1,175 @@
+// Copyright 2021 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package whitelist
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeFile writes a whitelist file named name in dir with contents.
+func writeFile(t *testing.T, dir, name, contents string) {
+	t.Helper()
+
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0o644); err != nil {
+		t.Fatalf("writeFile: %v", err)
+	}
+}
+
+// TestStoreMatchPerPlatform tests that Match matches a diagnostic against
+// its platform specific file, but not against a different platform's.
+func TestStoreMatchPerPlatform(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "linux_arm.txt", "1 a.go:6:2: known diagnostic\n")
+
+	s := NewStore(dir, false)
+
+	matched, err := s.Match("linux", "arm", "a.go:6:2: known diagnostic")
+	if err != nil {
+		t.Fatalf("Match: %v", err)
+	}
+	if !matched {
+		t.Error("want matched against linux_arm.txt, got false")
+	}
+
+	matched, err = s.Match("linux", "amd64", "a.go:6:2: known diagnostic")
+	if err != nil {
+		t.Fatalf("Match: %v", err)
+	}
+	if matched {
+		t.Error("want not matched against linux/amd64, got true")
+	}
+}
+
+// TestStoreMatchAll tests that Match consults all.txt for every platform.
+func TestStoreMatchAll(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "all.txt", "1 known on every platform\n")
+
+	s := NewStore(dir, false)
+
+	for _, sys := range [][2]string{{"linux", "amd64"}, {"windows", "386"}} {
+		matched, err := s.Match(sys[0], sys[1], "known on every platform")
+		if err != nil {
+			t.Fatalf("Match(%s, %s): %v", sys[0], sys[1], err)
+		}
+		if !matched {
+			t.Errorf("%s/%s: want matched against all.txt, got false", sys[0], sys[1])
+		}
+	}
+}
+
+// TestStoreMatchStripLines tests that, with stripLines set, Match ignores
+// the line number when matching a diagnostic against the whitelist.
+func TestStoreMatchStripLines(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "all.txt", "1 a.go: known diagnostic\n")
+
+	s := NewStore(dir, true)
+
+	matched, err := s.Match("linux", "amd64", "a.go:99: known diagnostic")
+	if err != nil {
+		t.Fatalf("Match: %v", err)
+	}
+	if !matched {
+		t.Error("want matched after stripping line numbers, got false")
+	}
+}
+
+// TestStrip tests that Strip removes the line number from a diagnostic
+// line.
+func TestStrip(t *testing.T) {
+	cases := []struct{ in, want string }{
+		{"a.go:42: message", "a.go: message"},
+		{"a.go:42:7: message", "a.go:7: message"},
+		{"message without a position", "message without a position"},
+	}
+
+	for _, c := range cases {
+		if got := Strip(c.in); got != c.want {
+			t.Errorf("Strip(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
+
+// TestLine tests that Line formats a diagnostic the same way a whitelist
+// file entry is written, for every combination of file and column.
+func TestLine(t *testing.T) {
+	cases := []struct {
+		file    string
+		lineno  int
+		col     int
+		message string
+		want    string
+	}{
+		{"a.go", 6, 2, "message", "a.go:6:2: message"},
+		{"a.go", 6, 0, "message", "a.go:6: message"},
+		{"", 0, 0, "message", "message"},
+	}
+
+	for _, c := range cases {
+		if got := Line(c.file, c.lineno, c.col, c.message); got != c.want {
+			t.Errorf("Line(%q, %d, %d, %q) = %q, want %q", c.file, c.lineno, c.col, c.message, got, c.want)
+		}
+	}
+}
+
+// TestStoreUnused tests that Unused reports a whitelist entry that was
+// never matched, or not matched as many times as expected, and only after
+// the file has actually been consulted.
+func TestStoreUnused(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "all.txt", "2 a known diagnostic\n")
+
+	s := NewStore(dir, false)
+	if len(s.Unused()) != 0 {
+		t.Error("want no unused entries before the file is consulted")
+	}
+
+	matched, err := s.Match("linux", "amd64", "a known diagnostic")
+	if err != nil {
+		t.Fatalf("Match: %v", err)
+	}
+	if !matched {
+		t.Fatal("want matched, got false")
+	}
+
+	unused := s.Unused()
+	if len(unused) != 1 {
+		t.Fatalf("want 1 unused entry, got %d: %+v", len(unused), unused)
+	}
+	if unused[0].Want != 2 || unused[0].Got != 1 {
+		t.Errorf("want Want=2 Got=1, got %+v", unused[0])
+	}
+}
+
+// TestStoreMatchConcurrent tests that Match is safe for concurrent use by
+// multiple platforms sharing all.txt, as happens when the worker pool
+// checks several platforms in parallel.
+func TestStoreMatchConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "all.txt", "8 shared diagnostic\n")
+
+	s := NewStore(dir, false)
+
+	done := make(chan bool, 8)
+	for i := 0; i < 8; i++ {
+		go func() {
+			matched, err := s.Match("linux", "amd64", "shared diagnostic")
+			done <- err == nil && matched
+		}()
+	}
+
+	for i := 0; i < 8; i++ {
+		if !<-done {
+			t.Error("want every concurrent Match to succeed and match")
+		}
+	}
+
+	if unused := s.Unused(); len(unused) != 0 {
+		t.Errorf("want no unused entries, got %+v", unused)
+	}
+}
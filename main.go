@@ -9,20 +9,33 @@
 package main
 
 import (
-	"bufio"
-	"bytes"
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strings"
 
-	"github.com/perillo/go-portable/internal/invoke"
+	"github.com/perillo/go-portable/internal/whitelist"
+	"github.com/perillo/go-portable/portable"
 )
 
-const usage = "Usage: go-portable [-first-class] [-mode <mode>] [packages]"
+const usage = "Usage: go-portable [-first-class] [-mode <mode>] [-j N] [-failfast] [-whitelist <dir>] [-n] [-format <format>] [-goarm <values>] [-goamd64 <values>] [-go386 <values>] [-gomips <values>] [-gomips64 <values>] [-goppc64 <values>] [-gowasm <values>] [-cgo <mode>] [-tags <tag>] [-buildmode <mode>] [packages]"
+
+// tagList is a repeatable -tags flag, collecting one -tags value per
+// occurrence on the command line.
+type tagList []string
+
+func (t *tagList) String() string { return strings.Join(*t, ",") }
+
+func (t *tagList) Set(value string) error {
+	*t = append(*t, value)
+
+	return nil
+}
 
 var (
 	// gocmd is the go command to use.  It can be overridden using the GOCMD
@@ -33,30 +46,32 @@ var (
 	gocmdshort string
 )
 
-// First class ports, taken from
-// https://github.com/golang/go/wiki/PortingPolicy#first-class-ports
-var firstClass = map[string]bool{
-	"linux/amd64":   true,
-	"linux/386":     true,
-	"linux/arm":     true,
-	"linux/arm64":   true,
-	"darwin/amd64":  true,
-	"windows/amd64": true,
-	"windows/386":   true,
-}
-
 // Flags.
 var (
-	mode    = flag.String("mode", "vet", "verification mode (vet or build)")
-	primary = flag.Bool("first-class", false, "use only first class ports")
+	mode         = flag.String("mode", "vet", "verification mode (vet or build)")
+	primary      = flag.Bool("first-class", false, "use only first class ports")
+	jobs         = flag.Int("j", runtime.NumCPU(), "number of platforms to verify in parallel")
+	failfast     = flag.Bool("failfast", false, "cancel outstanding jobs after the first fatal error")
+	whitelistDir = flag.String("whitelist", "", "directory of per platform whitelist files for known diagnostics")
+	stripLines   = flag.Bool("n", false, "strip line numbers when matching diagnostics against the whitelist")
+	format       = flag.String("format", "text", "output format (text, json or sarif)")
+
+	goarm    = flag.String("goarm", "5,6,7", "GOARM values to sweep for arm, comma separated")
+	goamd64  = flag.String("goamd64", "v1,v2,v3,v4", "GOAMD64 values to sweep for amd64, comma separated")
+	go386    = flag.String("go386", "sse2,softfloat", "GO386 values to sweep for 386, comma separated")
+	gomips   = flag.String("gomips", "hardfloat,softfloat", "GOMIPS values to sweep for mips/mipsle, comma separated")
+	gomips64 = flag.String("gomips64", "hardfloat,softfloat", "GOMIPS64 values to sweep for mips64/mips64le, comma separated")
+	goppc64  = flag.String("goppc64", "power8,power9,power10", "GOPPC64 values to sweep for ppc64/ppc64le, comma separated")
+	gowasm   = flag.String("gowasm", "none;satconv,signext", "GOWASM values to sweep for wasm, semicolon separated (\"none\" for no feature)")
+
+	cgo       = flag.String("cgo", "off", "CGO_ENABLED sweep mode (auto, on, off or both)")
+	tags      tagList
+	buildmode = flag.String("buildmode", "", "build mode forwarded to go build's -buildmode flag")
 )
 
-type platform struct {
-	os   string
-	arch string
-}
-
 func init() {
+	flag.Var(&tags, "tags", "build tag to pass to the underlying go command (repeatable)")
+
 	if value := os.Getenv("GOCMD"); value != "" {
 		gocmd = value
 	}
@@ -90,142 +105,126 @@ func main() {
 
 		os.Exit(2)
 	}
+	if *jobs < 1 {
+		fmt.Fprintf(os.Stderr, "invalid value %d for flag -j: must be >= 1\n", *jobs)
+		flag.Usage()
 
-	// Call godistlist outside the syntax function, so that we can detect a
-	// problem with the go tool early.
-	platforms, err := godistlist(*primary)
-	if err != nil {
-		log.Fatal(err)
+		os.Exit(2)
 	}
+	switch *format {
+	case "text", "json", "sarif":
+	default:
+		const err = `must be "text", "json" or "sarif"`
+		fmt.Fprintf(os.Stderr, "invalid value %q for flag -format: %s\n", *format, err)
+		flag.Usage()
 
-	if err := run(platforms, args, *mode); err != nil {
-		log.Fatal(err)
+		os.Exit(2)
 	}
-}
+	switch *cgo {
+	case "auto", "on", "off", "both":
+	default:
+		const err = `must be "auto", "on", "off" or "both"`
+		fmt.Fprintf(os.Stderr, "invalid value %q for flag -cgo: %s\n", *cgo, err)
+		flag.Usage()
 
-// run invokes go vet or go build for all the specified platforms.
-func run(platforms []platform, patterns []string, mode string) error {
-	tool := govet
-	if mode == "build" {
-		tool = gobuild
+		os.Exit(2)
 	}
 
-	nl := []byte("\n")
-	index := 0 // current failed platform
-
-	for _, sys := range platforms {
-		msg, err := tool(sys, patterns)
-		if err != nil {
-			return err
-		}
-		if msg == nil {
-			continue
-		}
-
-		// Print go vet diagnostic message.
-		if index > 0 {
-			os.Stderr.Write(nl)
-		}
-		fmt.Fprintf(os.Stderr, "%s/%s using %s\n", sys.os, sys.arch, gocmdshort)
-		os.Stderr.Write(msg)
-		os.Stderr.Write(nl)
-
-		index++
+	ctx := context.Background()
+
+	checker := &portable.Checker{
+		GoCmd:       gocmd,
+		Mode:        portable.Mode(*mode),
+		Concurrency: *jobs,
+		FailFast:    *failfast,
+		CGO:         portable.CGOMode(*cgo),
+		Tags:        tags,
+		BuildMode:   *buildmode,
+		Variants: portable.Variants{
+			GOARM:    splitList(*goarm, ","),
+			GOAMD64:  splitList(*goamd64, ","),
+			GO386:    splitList(*go386, ","),
+			GOMIPS:   splitList(*gomips, ","),
+			GOMIPS64: splitList(*gomips64, ","),
+			GOPPC64:  splitList(*goppc64, ","),
+			GOWASM:   splitGOWASM(*gowasm),
+		},
+	}
+	if *whitelistDir != "" {
+		checker.Whitelist = whitelist.NewStore(*whitelistDir, *stripLines)
 	}
 
-	return nil
-}
-
-// godistlist invokes go tool dist list to get a list of supported platforms.
-// When primary is true, only first class ports are included.
-func godistlist(primary bool) ([]platform, error) {
-	tool := gocmdshort + " tool dist list"
+	// Call ListPlatforms outside of Check, so that we can detect a problem
+	// with the go tool early.
+	platforms, err := checker.ListPlatforms(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *primary {
+		platforms = filterFirstClass(platforms)
+	}
+	checker.Platforms = platforms
 
-	cmd := exec.Command(gocmd, "tool", "dist", "list")
-	stdout, err := invoke.Output(cmd)
+	results, err := checker.Check(ctx, args)
 	if err != nil {
-		return nil, err
+		log.Fatal(err)
 	}
 
-	// Parse the list of os/arch pairs.
-	list := make([]platform, 0, 128) // preallocate memory
-	sc := bufio.NewScanner(bytes.NewReader(stdout))
-	for sc.Scan() {
-		line := sc.Text()
-		fields := strings.Split(line, "/")
-		if len(fields) != 2 {
-			return nil, fmt.Errorf("%s: invalid output: %q", tool, line)
-		}
+	failures := report(results, *format)
 
-		if primary && !firstClass[line] {
-			continue
+	if checker.Whitelist != nil {
+		for _, entry := range checker.Whitelist.Unused() {
+			fmt.Fprintf(os.Stderr, "%s: unused whitelist entry (want %d, got %d): %s\n",
+				entry.File, entry.Want, entry.Got, entry.Message)
 		}
+	}
 
-		ent := platform{
-			os:   fields[0],
-			arch: fields[1],
-		}
-		list = append(list, ent)
+	if failures > 0 {
+		os.Exit(1)
 	}
-	if err := sc.Err(); err != nil {
-		return nil, fmt.Errorf("%s, internal error: %v", tool, err)
+}
+
+// filterFirstClass returns the subset of platforms that are first class
+// ports.
+func filterFirstClass(platforms []portable.Platform) []portable.Platform {
+	list := make([]portable.Platform, 0, len(platforms))
+	for _, p := range platforms {
+		if portable.FirstClass[p.OS+"/"+p.Arch] {
+			list = append(list, p)
+		}
 	}
 
-	return list, nil
+	return list
 }
 
-// govet invokes go vet on the packages named by the given patterns, for the
-// specified platform.  It returns the diagnostic message and a non nil error,
-// in case of a fatal error like go command not found.
-func govet(sys platform, patterns []string) ([]byte, error) {
-	args := append([]string{"vet"}, patterns...)
-	cmd := exec.Command(gocmd, args...)
-	cmd.Env = append(os.Environ(), "GOOS="+sys.os, "GOARCH="+sys.arch)
-
-	if err := invoke.Run(cmd); err != nil {
-		cmderr := err.(*invoke.Error)
-
-		// Determine the error type to decide if there was a fatal problem
-		// with the invocation of go vet that requires the termination of
-		// the program.
-		switch cmderr.Err.(type) {
-		case *exec.Error:
-			return nil, err
-		case *exec.ExitError:
-			return cmderr.Stderr, nil
-		}
+// splitList splits a flag value into a list of trimmed, non empty fields.
+// It returns nil, meaning "use the default variant matrix", for an empty
+// value.
+func splitList(value, sep string) []string {
+	if value == "" {
+		return nil
+	}
 
-		return nil, err // should not be reached
+	fields := make([]string, 0)
+	for _, f := range strings.Split(value, sep) {
+		if f = strings.TrimSpace(f); f != "" {
+			fields = append(fields, f)
+		}
 	}
 
-	return nil, nil
+	return fields
 }
 
-// gobuild invokes go build on the packages named by the given patterns, for
-// the specified platform.  It returns the diagnostic message and a non nil
-// error, in case of a fatal error like go command not found.
-func gobuild(sys platform, patterns []string) ([]byte, error) {
-	// NOTE(mperillo): Only go1.8 and later are supported in gobuild.
-	args := append([]string{"build"}, "-o", os.DevNull)
-	args = append(args, patterns...)
-	cmd := exec.Command(gocmd, args...)
-	cmd.Env = append(os.Environ(), "GOOS="+sys.os, "GOARCH="+sys.arch, "CGO_ENABLED=0")
-
-	if err := invoke.Run(cmd); err != nil {
-		cmderr := err.(*invoke.Error)
-
-		// Determine the error type to decide if there was a fatal problem
-		// with the invocation of go build that requires the termination of
-		// the program.
-		switch cmderr.Err.(type) {
-		case *exec.Error:
-			return nil, err
-		case *exec.ExitError:
-			return cmderr.Stderr, nil
+// splitGOWASM splits the -gowasm flag value, which uses ";" to separate
+// GOWASM values since a value may itself be a comma separated feature
+// list (e.g. "satconv,signext"), and maps the placeholder "none" to "".
+func splitGOWASM(value string) []string {
+	fields := splitList(value, ";")
+	for i, f := range fields {
+		if f == "none" {
+			fields[i] = ""
 		}
-
-		return nil, err // should not be reached
 	}
 
-	return nil, nil
+	return fields
 }
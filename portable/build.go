@@ -0,0 +1,60 @@
+// Copyright 2021 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package portable
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/perillo/go-portable/internal/invoke"
+)
+
+// gobuild invokes go build on the packages named by patterns, for the
+// specified platform and CGO_ENABLED value ("0", "1", or "" to leave it
+// unset).  It returns a Result with the parsed diagnostics, and a non nil
+// error in case of a fatal error like the go command not being found.
+func (c *Checker) gobuild(ctx context.Context, sys Platform, cgo string, patterns []string) (Result, error) {
+	res := Result{Platform: sys, Tool: string(ModeBuild), CGOEnabled: cgo}
+
+	// NOTE(mperillo): Only go1.8 and later are supported in gobuild.
+	args := append([]string{"build"}, "-o", os.DevNull)
+	if len(c.Tags) > 0 {
+		args = append(args, "-tags", strings.Join(c.Tags, ","))
+	}
+	if c.BuildMode != "" {
+		args = append(args, "-buildmode="+c.BuildMode)
+	}
+	args = append(args, patterns...)
+	cmd := exec.CommandContext(ctx, c.GoCmd, args...)
+	cmd.Env = append(os.Environ(), "GOOS="+sys.OS, "GOARCH="+sys.Arch)
+	cmd.Env = append(cmd.Env, sys.env()...)
+	if cgo != "" {
+		cmd.Env = append(cmd.Env, "CGO_ENABLED="+cgo)
+	}
+	cmd.Env = append(cmd.Env, c.Env...)
+
+	if err := invoke.Run(cmd); err != nil {
+		cmderr := err.(*invoke.Error)
+
+		// Determine the error type to decide if there was a fatal problem
+		// with the invocation of go build that requires aborting the
+		// check.
+		switch cmderr.Err.(type) {
+		case *exec.Error:
+			return res, err
+		case *exec.ExitError:
+			res.Diagnostics = parseBuildDiagnostics(cmderr.Stderr)
+			res.ExitCode = exitCode(cmderr.Err)
+
+			return res, nil
+		}
+
+		return res, err // should not be reached
+	}
+
+	return res, nil
+}
@@ -0,0 +1,69 @@
+// Copyright 2021 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package portable
+
+import (
+	"os"
+	"runtime"
+)
+
+// CGOMode selects which CGO_ENABLED values Check sweeps for a platform.
+type CGOMode string
+
+// Supported CGO modes.
+const (
+	// CGOAuto leaves CGO_ENABLED unset, letting the go command apply its
+	// own default (enabled for native builds, disabled when cross
+	// compiling).
+	CGOAuto CGOMode = "auto"
+
+	// CGOOn always sets CGO_ENABLED=1.
+	CGOOn CGOMode = "on"
+
+	// CGOOff always sets CGO_ENABLED=0.  This is the default.  It matches
+	// go-portable's historical behavior for ModeBuild, which always forced
+	// CGO_ENABLED=0; for ModeVet, which historically left CGO_ENABLED
+	// unset (equivalent to CGOAuto), this is a deliberate change, so that
+	// the default is consistent and predictable across both modes.
+	CGOOff CGOMode = "off"
+
+	// CGOBoth verifies every platform twice, with CGO_ENABLED=0 and
+	// CGO_ENABLED=1, skipping the latter for platforms that cannot be
+	// cross compiled with cgo from the host.
+	CGOBoth CGOMode = "both"
+)
+
+// cgoValues returns the CGO_ENABLED values ("0", "1" or "" for "let the go
+// command decide") to verify sys with, according to c.CGO.
+func (c *Checker) cgoValues(sys Platform) []string {
+	switch c.CGO {
+	case CGOAuto:
+		return []string{""}
+	case CGOOn:
+		return []string{"1"}
+	case CGOBoth:
+		values := []string{"0"}
+		if cgoCrossable(sys) {
+			values = append(values, "1")
+		}
+
+		return values
+	default: // CGOOff, and the zero value
+		return []string{"0"}
+	}
+}
+
+// cgoCrossable reports whether cgo can plausibly be cross compiled for sys
+// from the host.  Building with cgo for a foreign GOOS/GOARCH normally
+// requires a configured C cross compiler; lacking a reliable way to probe
+// for one, cgoCrossable only allows it for a native build, or when CC is
+// set explicitly.
+func cgoCrossable(sys Platform) bool {
+	if sys.OS == runtime.GOOS && sys.Arch == runtime.GOARCH {
+		return true
+	}
+
+	return os.Getenv("CC") != ""
+}
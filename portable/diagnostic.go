@@ -0,0 +1,141 @@
+// Copyright 2021 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package portable
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// diagLine matches a "file:line:col: message" or "file:line: message"
+// diagnostic, as produced by go build.
+var diagLine = regexp.MustCompile(`^(.+?):(\d+):(?:(\d+):)?\s(.*)$`)
+
+// parseBuildDiagnostics parses the line oriented diagnostic output of go
+// build into a flat list of Diagnostic.  Lines that don't match the
+// "file:line[:col]: message" shape, such as continuation lines, are kept
+// as plain messages.
+func parseBuildDiagnostics(msg []byte) []Diagnostic {
+	var diags []Diagnostic
+
+	sc := bufio.NewScanner(bytes.NewReader(msg))
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" {
+			continue
+		}
+
+		m := diagLine.FindStringSubmatch(line)
+		if m == nil {
+			diags = append(diags, Diagnostic{Message: line})
+			continue
+		}
+
+		lineno, _ := strconv.Atoi(m[2])
+		col, _ := strconv.Atoi(m[3]) // 0 if absent
+		diags = append(diags, Diagnostic{File: m[1], Line: lineno, Column: col, Message: m[4]})
+	}
+
+	return diags
+}
+
+// vetDiagnostic is the shape of a single entry in the map produced by go
+// vet -json, e.g. {"posn": "file.go:12:3", "message": "..."}.
+type vetDiagnostic struct {
+	Posn    string `json:"posn"`
+	Message string `json:"message"`
+}
+
+// vetBanner matches the "# <import path>" banner that go vet prints to
+// standard error before the report for each analyzed package, whether or
+// not -json is used.
+var vetBanner = regexp.MustCompile(`(?m)^# .*\n`)
+
+// splitVetBlocks splits the standard error of a `go vet -json` invocation
+// into one block per analyzed package, stripping the "# <import path>"
+// banner that precedes each one.
+func splitVetBlocks(raw []byte) [][]byte {
+	idx := vetBanner.FindAllIndex(raw, -1)
+	if idx == nil {
+		return [][]byte{raw}
+	}
+
+	blocks := make([][]byte, 0, len(idx))
+	for i, m := range idx {
+		end := len(raw)
+		if i+1 < len(idx) {
+			end = idx[i+1][0]
+		}
+		blocks = append(blocks, raw[m[1]:end])
+	}
+
+	return blocks
+}
+
+// parseVetDiagnostics parses the standard error of a `go vet -json`
+// invocation into a flat list of Diagnostic.
+//
+// Each analyzed package contributes one block, banner included, holding
+// either a JSON object of the form map[package]map[analyzer][]
+// vetDiagnostic, or, when the package fails to compile, a plain
+// "vet: file:line:col: message" line, the same as a pre-1.10 vet error.
+// parseVetDiagnostics handles both shapes.
+func parseVetDiagnostics(raw []byte) []Diagnostic {
+	var diags []Diagnostic
+
+	for _, block := range splitVetBlocks(raw) {
+		if len(bytes.TrimSpace(block)) == 0 {
+			continue
+		}
+
+		var report map[string]map[string][]vetDiagnostic
+		if err := json.Unmarshal(block, &report); err != nil {
+			diags = append(diags, parseBuildDiagnostics(stripVetPrefix(block))...)
+			continue
+		}
+
+		for _, analyzers := range report {
+			for analyzer, entries := range analyzers {
+				for _, e := range entries {
+					file, lineno, col := splitPosn(e.Posn)
+					diags = append(diags, Diagnostic{
+						Analyzer: analyzer,
+						File:     file,
+						Line:     lineno,
+						Column:   col,
+						Message:  e.Message,
+					})
+				}
+			}
+		}
+	}
+
+	return diags
+}
+
+// stripVetPrefix removes the "vet: " prefix go vet adds to the fallback
+// plain text error it reports when a package fails to compile.
+func stripVetPrefix(block []byte) []byte {
+	return bytes.TrimPrefix(bytes.TrimSpace(block), []byte("vet: "))
+}
+
+// splitPosn splits a "file:line:col" position, as reported by go vet
+// -json, into its components.
+func splitPosn(posn string) (file string, line, col int) {
+	fields := strings.Split(posn, ":")
+	if len(fields) < 3 {
+		return posn, 0, 0
+	}
+
+	file = strings.Join(fields[:len(fields)-2], ":")
+	line, _ = strconv.Atoi(fields[len(fields)-2])
+	col, _ = strconv.Atoi(fields[len(fields)-1])
+
+	return file, line, col
+}
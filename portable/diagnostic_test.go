@@ -0,0 +1,78 @@
+// Copyright 2021 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package portable
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestParseVetDiagnosticsJSON tests parseVetDiagnostics against the
+// standard error of a real `go vet -json` invocation that found a
+// diagnostic in two packages.
+func TestParseVetDiagnosticsJSON(t *testing.T) {
+	const raw = `# example.com/a
+{
+	"example.com/a": {
+		"printf": [
+			{
+				"posn": "/tmp/a/a.go:6:2",
+				"message": "fmt.Printf format %d has arg \"oops\" of wrong type string"
+			}
+		]
+	}
+}
+# example.com/a/b
+{
+	"example.com/a/b": {
+		"printf": [
+			{
+				"posn": "/tmp/a/b/b.go:6:2",
+				"message": "fmt.Printf format %d has arg \"bad\" of wrong type string"
+			}
+		]
+	}
+}
+`
+
+	want := []Diagnostic{
+		{
+			Analyzer: "printf",
+			File:     "/tmp/a/a.go",
+			Line:     6,
+			Column:   2,
+			Message:  `fmt.Printf format %d has arg "oops" of wrong type string`,
+		},
+		{
+			Analyzer: "printf",
+			File:     "/tmp/a/b/b.go",
+			Line:     6,
+			Column:   2,
+			Message:  `fmt.Printf format %d has arg "bad" of wrong type string`,
+		},
+	}
+
+	got := parseVetDiagnostics([]byte(raw))
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %+v, got %+v", want, got)
+	}
+}
+
+// TestParseVetDiagnosticsCompileError tests parseVetDiagnostics against
+// the fallback plain text error go vet -json reports, on standard error,
+// when a package fails to compile.
+func TestParseVetDiagnosticsCompileError(t *testing.T) {
+	const raw = "# example.com/a\n" +
+		"vet: ./a.go:3:20: expected ')', found '{'\n"
+
+	want := []Diagnostic{
+		{File: "./a.go", Line: 3, Column: 20, Message: "expected ')', found '{'"},
+	}
+
+	got := parseVetDiagnostics([]byte(raw))
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %+v, got %+v", want, got)
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2021 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package portable
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/perillo/go-portable/internal/invoke"
+)
+
+// FirstClass lists the first class ports, keyed by "os/arch", taken from
+// https://github.com/golang/go/wiki/PortingPolicy#first-class-ports
+var FirstClass = map[string]bool{
+	"linux/amd64":   true,
+	"linux/386":     true,
+	"linux/arm":     true,
+	"linux/arm64":   true,
+	"darwin/amd64":  true,
+	"windows/amd64": true,
+	"windows/386":   true,
+}
+
+// godistlist invokes `go tool dist list` to get the list of platforms
+// supported by gocmd.
+func godistlist(ctx context.Context, gocmd string) ([]Platform, error) {
+	tool := filepath.Base(gocmd) + " tool dist list"
+
+	cmd := exec.CommandContext(ctx, gocmd, "tool", "dist", "list")
+	stdout, err := invoke.Output(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse the list of os/arch pairs.
+	list := make([]Platform, 0, 128) // preallocate memory
+	sc := bufio.NewScanner(bytes.NewReader(stdout))
+	for sc.Scan() {
+		line := sc.Text()
+		fields := strings.Split(line, "/")
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s: invalid output: %q", tool, line)
+		}
+
+		list = append(list, Platform{OS: fields[0], Arch: fields[1]})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("%s, internal error: %v", tool, err)
+	}
+
+	return list, nil
+}
@@ -0,0 +1,318 @@
+// Copyright 2021 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package portable checks if a package is compatible with other platforms.
+//
+// Internally, it invokes `go vet` or `go build` on a set of platforms, as
+// reported by `go tool dist list`, and reports the diagnostics for every
+// platform that is not compatible.  It is the library underlying the
+// go-portable command; other tools can import it to embed portability
+// checks without shelling out to go-portable itself.
+package portable
+
+import (
+	"context"
+	"os/exec"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/perillo/go-portable/internal/whitelist"
+)
+
+// Mode selects the verification performed for every platform.
+type Mode string
+
+// Supported modes.
+const (
+	ModeVet   Mode = "vet"
+	ModeBuild Mode = "build"
+)
+
+// Platform is a GOOS/GOARCH pair, as reported by `go tool dist list`,
+// optionally together with one of the per-arch microarchitecture
+// subvariants defined by cmd/dist (GOARM, GOAMD64, GO386, GOMIPS,
+// GOMIPS64, GOPPC64 or GOWASM).  At most one of the variant fields is set,
+// depending on Arch.
+type Platform struct {
+	OS   string
+	Arch string
+
+	GOARM    string `json:"goarm,omitempty"`
+	GOAMD64  string `json:"goamd64,omitempty"`
+	GO386    string `json:"go386,omitempty"`
+	GOMIPS   string `json:"gomips,omitempty"`
+	GOMIPS64 string `json:"gomips64,omitempty"`
+	GOPPC64  string `json:"goppc64,omitempty"`
+	GOWASM   string `json:"gowasm,omitempty"`
+}
+
+// String returns the platform in "os/arch" form, followed by its
+// microarchitecture variant, if any, e.g. "linux/arm GOARM=5".
+func (p Platform) String() string {
+	s := p.OS + "/" + p.Arch
+	if env := p.env(); len(env) > 0 {
+		s += " " + env[0]
+	}
+
+	return s
+}
+
+// env returns the GOARM/GOAMD64/... environment variable, in "key=value"
+// form, for whichever variant field of p is set.
+func (p Platform) env() []string {
+	var env []string
+	add := func(key, value string) {
+		if value != "" {
+			env = append(env, key+"="+value)
+		}
+	}
+
+	add("GOARM", p.GOARM)
+	add("GOAMD64", p.GOAMD64)
+	add("GO386", p.GO386)
+	add("GOMIPS", p.GOMIPS)
+	add("GOMIPS64", p.GOMIPS64)
+	add("GOPPC64", p.GOPPC64)
+	add("GOWASM", p.GOWASM)
+
+	return env
+}
+
+// Diagnostic is a single portability diagnostic reported by go vet or go
+// build for a platform.
+type Diagnostic struct {
+	Analyzer string `json:"analyzer,omitempty"` // go vet analyzer name, when known
+	File     string `json:"file,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+	Message  string `json:"message"`
+}
+
+// Result is the outcome of verifying a single platform.
+type Result struct {
+	Platform    Platform     `json:"platform"`
+	Tool        string       `json:"tool"`
+	CGOEnabled  string       `json:"cgo_enabled,omitempty"` // "0" or "1"; empty when left to the go command's default
+	Diagnostics []Diagnostic `json:"diagnostics"`
+	ExitCode    int          `json:"exit_code"`
+}
+
+// Checker verifies the portability of one or more packages across a set of
+// platforms.
+type Checker struct {
+	// GoCmd is the path or base name of the go command to invoke.  It is
+	// the caller's responsibility to resolve it, e.g. via exec.LookPath.
+	GoCmd string
+
+	// Mode selects whether to run go vet or go build for every platform.
+	Mode Mode
+
+	// Platforms is the set of platforms to verify.  Use ListPlatforms to
+	// discover them, or supply a custom list, e.g. loaded from a policy
+	// file.
+	Platforms []Platform
+
+	// Variants restricts the microarchitecture subvariants ListPlatforms
+	// sweeps for GOARM, GOAMD64 and similar arches.  A nil field sweeps the
+	// standard matrix defined by cmd/dist; see DefaultVariants.
+	Variants Variants
+
+	// Env holds extra "key=value" environment variables set for every
+	// invocation, in addition to GOOS and GOARCH.
+	Env []string
+
+	// CGO selects which CGO_ENABLED values to sweep.  The zero value is
+	// equivalent to CGOOff; see its documentation for how that compares to
+	// go-portable's pre-CGOMode behavior.
+	CGO CGOMode
+
+	// Tags is forwarded as a comma joined list to the underlying go
+	// command's -tags flag.
+	Tags []string
+
+	// BuildMode is forwarded to go build's -buildmode flag.  It has no
+	// effect when Mode is ModeVet.
+	BuildMode string
+
+	// Concurrency is the number of platforms verified in parallel.  A value
+	// <= 0 means runtime.NumCPU().
+	Concurrency int
+
+	// FailFast cancels outstanding platform checks as soon as one of them
+	// returns a fatal error (e.g. the go command is not found).
+	FailFast bool
+
+	// Whitelist, when set, suppresses known diagnostics, as reported by
+	// Check, on a per platform basis.
+	Whitelist *whitelist.Store
+}
+
+// ListPlatforms invokes `go tool dist list` to discover the platforms
+// supported by the go command referenced by c.GoCmd, and expands each one
+// that has microarchitecture subvariants (e.g. linux/arm) into one
+// Platform per variant allowed by c.Variants.
+func (c *Checker) ListPlatforms(ctx context.Context) ([]Platform, error) {
+	base, err := godistlist(ctx, c.GoCmd)
+	if err != nil {
+		return nil, err
+	}
+
+	return expandVariants(base, c.Variants), nil
+}
+
+// job is a unit of work submitted to the worker pool by Check.  A platform
+// verified under CGOBoth contributes two jobs, one per CGO_ENABLED value.
+type job struct {
+	index int
+	sys   Platform
+	cgo   string
+}
+
+// workResult is the outcome of verifying a single platform, as produced by
+// a worker and collected by Check.
+type workResult struct {
+	index int
+	res   Result
+	err   error
+}
+
+// Check verifies every platform in c.Platforms against the packages named
+// by patterns, using a pool of c.Concurrency workers.  A platform verified
+// under CGOBoth contributes two results, one per CGO_ENABLED value.
+// Results are returned in the same order as c.Platforms (and, within a
+// platform, CGO_ENABLED=0 before CGO_ENABLED=1), regardless of the order
+// in which the workers complete.
+//
+// Check returns a non nil error if a fatal error occurs, such as the go
+// command not being found, together with every result that was gathered
+// successfully.  Outstanding platform checks are cancelled after the first
+// fatal error only when c.FailFast is set; otherwise every platform is
+// still verified before Check returns.
+func (c *Checker) Check(ctx context.Context, patterns []string) ([]Result, error) {
+	tool := c.govet
+	if c.Mode == ModeBuild {
+		tool = c.gobuild
+	}
+
+	concurrency := c.Concurrency
+	if concurrency <= 0 {
+		concurrency = runtime.NumCPU()
+	}
+
+	var jobs []job
+	for _, sys := range c.Platforms {
+		for _, cgo := range c.cgoValues(sys) {
+			jobs = append(jobs, job{index: len(jobs), sys: sys, cgo: cgo})
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobsCh := make(chan job)
+	resultsCh := make(chan workResult)
+
+	var wg sync.WaitGroup
+	wg.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go func() {
+			defer wg.Done()
+
+			for j := range jobsCh {
+				res, err := tool(ctx, j.sys, j.cgo, patterns)
+				if err == nil && c.Whitelist != nil {
+					res.Diagnostics, err = filterWhitelist(c.Whitelist, res)
+				}
+				resultsCh <- workResult{index: j.index, res: res, err: err}
+			}
+		}()
+	}
+
+	go func() {
+		for _, j := range jobs {
+			select {
+			case jobsCh <- j:
+			case <-ctx.Done():
+				close(jobsCh)
+				return
+			}
+		}
+		close(jobsCh)
+	}()
+
+	go func() {
+		wg.Wait()
+		close(resultsCh)
+	}()
+
+	results := make([]Result, len(jobs))
+	received := make([]bool, len(jobs))
+	var failures atomic.Uint32
+	var fatal error
+
+	for r := range resultsCh {
+		if r.err != nil {
+			if fatal == nil {
+				fatal = r.err
+				if c.FailFast {
+					cancel()
+				}
+			}
+			continue
+		}
+
+		if len(r.res.Diagnostics) > 0 {
+			failures.Add(1)
+		}
+		results[r.index] = r.res
+		received[r.index] = true
+	}
+
+	if fatal != nil {
+		// Only return the results that were actually gathered.
+		out := make([]Result, 0, len(results))
+		for i, ok := range received {
+			if ok {
+				out = append(out, results[i])
+			}
+		}
+
+		return out, fatal
+	}
+
+	return results, nil
+}
+
+// filterWhitelist removes the whitelisted diagnostics from res, consulting
+// store for res.Platform.
+func filterWhitelist(store *whitelist.Store, res Result) ([]Diagnostic, error) {
+	if len(res.Diagnostics) == 0 {
+		return res.Diagnostics, nil
+	}
+
+	kept := make([]Diagnostic, 0, len(res.Diagnostics))
+	for _, d := range res.Diagnostics {
+		line := whitelist.Line(d.File, d.Line, d.Column, d.Message)
+		matched, err := store.Match(res.Platform.OS, res.Platform.Arch, line)
+		if err != nil {
+			return nil, err
+		}
+		if !matched {
+			kept = append(kept, d)
+		}
+	}
+
+	return kept, nil
+}
+
+// exitCode extracts the process exit code from a *exec.ExitError, returning
+// 0 for any other error.
+func exitCode(err error) int {
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return exitErr.ExitCode()
+	}
+
+	return 0
+}
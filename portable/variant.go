@@ -0,0 +1,117 @@
+// Copyright 2021 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package portable
+
+// Variants restricts, per arch, which microarchitecture subvariants
+// ListPlatforms sweeps.  A nil field means: sweep every value in
+// DefaultVariants for that field.
+type Variants struct {
+	GOARM    []string
+	GOAMD64  []string
+	GO386    []string
+	GOMIPS   []string
+	GOMIPS64 []string
+	GOPPC64  []string
+	GOWASM   []string
+}
+
+// DefaultVariants is the standard subvariant matrix defined by cmd/dist.
+// GOWASM="" means the wasm build with no extra features enabled.
+var DefaultVariants = Variants{
+	GOARM:    []string{"5", "6", "7"},
+	GOAMD64:  []string{"v1", "v2", "v3", "v4"},
+	GO386:    []string{"sse2", "softfloat"},
+	GOMIPS:   []string{"hardfloat", "softfloat"},
+	GOMIPS64: []string{"hardfloat", "softfloat"},
+	GOPPC64:  []string{"power8", "power9", "power10"},
+	GOWASM:   []string{"", "satconv,signext"},
+}
+
+// resolve fills in any nil field of v with the corresponding field of
+// DefaultVariants.
+func (v Variants) resolve() Variants {
+	if v.GOARM == nil {
+		v.GOARM = DefaultVariants.GOARM
+	}
+	if v.GOAMD64 == nil {
+		v.GOAMD64 = DefaultVariants.GOAMD64
+	}
+	if v.GO386 == nil {
+		v.GO386 = DefaultVariants.GO386
+	}
+	if v.GOMIPS == nil {
+		v.GOMIPS = DefaultVariants.GOMIPS
+	}
+	if v.GOMIPS64 == nil {
+		v.GOMIPS64 = DefaultVariants.GOMIPS64
+	}
+	if v.GOPPC64 == nil {
+		v.GOPPC64 = DefaultVariants.GOPPC64
+	}
+	if v.GOWASM == nil {
+		v.GOWASM = DefaultVariants.GOWASM
+	}
+
+	return v
+}
+
+// expandVariants expands every platform in base that has microarchitecture
+// subvariants into one Platform per variant allowed by variants, leaving
+// every other platform untouched.
+func expandVariants(base []Platform, variants Variants) []Platform {
+	v := variants.resolve()
+
+	list := make([]Platform, 0, len(base))
+	for _, p := range base {
+		switch {
+		case p.Arch == "arm":
+			for _, val := range v.GOARM {
+				q := p
+				q.GOARM = val
+				list = append(list, q)
+			}
+		case p.Arch == "amd64":
+			for _, val := range v.GOAMD64 {
+				q := p
+				q.GOAMD64 = val
+				list = append(list, q)
+			}
+		case p.Arch == "386":
+			for _, val := range v.GO386 {
+				q := p
+				q.GO386 = val
+				list = append(list, q)
+			}
+		case p.Arch == "mips" || p.Arch == "mipsle":
+			for _, val := range v.GOMIPS {
+				q := p
+				q.GOMIPS = val
+				list = append(list, q)
+			}
+		case p.Arch == "mips64" || p.Arch == "mips64le":
+			for _, val := range v.GOMIPS64 {
+				q := p
+				q.GOMIPS64 = val
+				list = append(list, q)
+			}
+		case p.Arch == "ppc64" || p.Arch == "ppc64le":
+			for _, val := range v.GOPPC64 {
+				q := p
+				q.GOPPC64 = val
+				list = append(list, q)
+			}
+		case p.Arch == "wasm":
+			for _, val := range v.GOWASM {
+				q := p
+				q.GOWASM = val
+				list = append(list, q)
+			}
+		default:
+			list = append(list, p)
+		}
+	}
+
+	return list
+}
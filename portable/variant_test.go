@@ -0,0 +1,73 @@
+// Copyright 2021 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package portable
+
+import "testing"
+
+// TestExpandVariantsARM tests that expandVariants sweeps GOARM for every
+// arm port, not just linux/arm.
+func TestExpandVariantsARM(t *testing.T) {
+	base := []Platform{
+		{OS: "linux", Arch: "arm"},
+		{OS: "android", Arch: "arm"},
+		{OS: "freebsd", Arch: "arm"},
+		{OS: "windows", Arch: "amd64"}, // not arm, GOARM stays unset
+	}
+	variants := Variants{GOARM: []string{"6", "7"}}
+
+	got := expandVariants(base, variants)
+
+	wantARM := 0
+	for _, p := range got {
+		if p.Arch != "arm" {
+			continue
+		}
+		if p.GOARM == "" {
+			t.Errorf("%s/%s: want GOARM set, got empty", p.OS, p.Arch)
+		}
+		wantARM++
+	}
+	if want := len(variants.GOARM) * 3; wantARM != want {
+		t.Errorf("want %d expanded arm platforms, got %d", want, wantARM)
+	}
+
+	for _, p := range got {
+		if p.Arch == "amd64" && p.GOARM != "" {
+			t.Errorf("windows/amd64: want GOARM unset, got %q", p.GOARM)
+		}
+	}
+}
+
+// TestExpandVariantsDefault tests that a nil Variants field falls back to
+// DefaultVariants.
+func TestExpandVariantsDefault(t *testing.T) {
+	base := []Platform{{OS: "linux", Arch: "arm"}}
+
+	got := expandVariants(base, Variants{})
+	if len(got) != len(DefaultVariants.GOARM) {
+		t.Fatalf("want %d platforms, got %d", len(DefaultVariants.GOARM), len(got))
+	}
+
+	seen := make(map[string]bool)
+	for _, p := range got {
+		seen[p.GOARM] = true
+	}
+	for _, val := range DefaultVariants.GOARM {
+		if !seen[val] {
+			t.Errorf("missing GOARM=%s in expanded platforms", val)
+		}
+	}
+}
+
+// TestExpandVariantsUntouched tests that a platform with no
+// microarchitecture subvariants passes through unchanged.
+func TestExpandVariantsUntouched(t *testing.T) {
+	base := []Platform{{OS: "plan9", Arch: "arm64"}}
+
+	got := expandVariants(base, Variants{})
+	if len(got) != 1 || got[0] != base[0] {
+		t.Errorf("want %+v unchanged, got %+v", base[0], got)
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2021 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package portable
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/perillo/go-portable/internal/invoke"
+)
+
+// govet invokes go vet, with -json, on the packages named by patterns, for
+// the specified platform and CGO_ENABLED value ("0", "1", or "" to leave
+// it unset).  It returns a Result with the parsed diagnostics, and a non
+// nil error in case of a fatal error like the go command not being found.
+//
+// NOTE(mperillo): older go commands write the "# <import path>" banner and
+// the JSON report of go vet -json to standard error; newer ones write the
+// report to standard output, one JSON object per package with no banner,
+// and keep standard error for the plain text error of a package that fails
+// to compile.  Both streams are parsed.  Unlike go build, go vet -json
+// exits 0 as soon as the packages compile, regardless of whether it found
+// any diagnostic; a non 0 exit status instead means a package failed to
+// compile.  Since the report has to be read on the success path too,
+// invoke.Run and invoke.Output, which only surface captured output inside
+// the error returned for a failed command, can't be used here: both
+// streams are captured directly instead.
+func (c *Checker) govet(ctx context.Context, sys Platform, cgo string, patterns []string) (Result, error) {
+	res := Result{Platform: sys, Tool: string(ModeVet), CGOEnabled: cgo}
+
+	args := []string{"vet", "-json"}
+	if len(c.Tags) > 0 {
+		args = append(args, "-tags", strings.Join(c.Tags, ","))
+	}
+	args = append(args, patterns...)
+	cmd := exec.CommandContext(ctx, c.GoCmd, args...)
+	cmd.Env = append(os.Environ(), "GOOS="+sys.OS, "GOARCH="+sys.Arch)
+	cmd.Env = append(cmd.Env, sys.env()...)
+	if cgo != "" {
+		cmd.Env = append(cmd.Env, "CGO_ENABLED="+cgo)
+	}
+	cmd.Env = append(cmd.Env, c.Env...)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err != nil {
+		// Determine the error type to decide if there was a fatal problem
+		// with the invocation of go vet that requires aborting the check.
+		if _, ok := err.(*exec.ExitError); !ok {
+			return res, &invoke.Error{
+				Cmd:    cmd.Path,
+				Argv:   args,
+				Stderr: bytes.TrimSpace(stderr.Bytes()),
+				Err:    err,
+			}
+		}
+	}
+
+	res.Diagnostics = parseVetDiagnostics(stderr.Bytes())
+	dec := json.NewDecoder(&stdout)
+	for dec.More() {
+		var report json.RawMessage
+		if err := dec.Decode(&report); err != nil {
+			break
+		}
+		res.Diagnostics = append(res.Diagnostics, parseVetDiagnostics(report)...)
+	}
+	res.ExitCode = exitCode(err)
+
+	return res, nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2021 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package portable
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// TestGovet tests govet against a real `go vet -json` invocation on a
+// temporary package with a known printf diagnostic, for the host
+// platform.
+func TestGovet(t *testing.T) {
+	gocmd, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+
+	dir := tempPackage(t)
+	sys := Platform{OS: runtime.GOOS, Arch: runtime.GOARCH}
+	c := &Checker{GoCmd: gocmd, Mode: ModeVet}
+
+	res, err := c.govet(context.Background(), sys, "", []string{"./..."})
+	if err != nil {
+		t.Fatalf("govet: %v", err)
+	}
+	if len(res.Diagnostics) != 1 {
+		t.Fatalf("want 1 diagnostic, got %d: %+v", len(res.Diagnostics), res.Diagnostics)
+	}
+
+	d := res.Diagnostics[0]
+	if d.Analyzer != "printf" {
+		t.Errorf("want analyzer printf, got %s", d.Analyzer)
+	}
+	if d.File != filepath.Join(dir, "main.go") {
+		t.Errorf("want file %s, got %s", filepath.Join(dir, "main.go"), d.File)
+	}
+}
+
+// tempPackage creates a temporary module with a single package containing
+// a known printf format bug, and changes the current directory to it for
+// the duration of the test.
+func tempPackage(t *testing.T) string {
+	dir := t.TempDir()
+
+	const gomod = "module example.com/tmp\n\ngo 1.16\n"
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte(gomod), 0o644); err != nil {
+		t.Fatalf("tempPackage: %v", err)
+	}
+
+	const src = `package main
+
+import "fmt"
+
+func main() {
+	fmt.Printf("%d\n", "oops")
+}
+`
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(src), 0o644); err != nil {
+		t.Fatalf("tempPackage: %v", err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("tempPackage: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("tempPackage: %v", err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	return dir
+}